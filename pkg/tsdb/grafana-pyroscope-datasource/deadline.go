@@ -0,0 +1,128 @@
+package pyroscope
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+)
+
+// ErrQueryTimeout is returned when a Connect call does not complete before
+// the client's configured query timeout, as opposed to a generic error
+// coming back from the Connect transport.
+var ErrQueryTimeout = errors.New("pyroscope: query exceeded the configured timeout")
+
+const (
+	maxQueryAttempts = 3
+	initialBackoff   = 100 * time.Millisecond
+)
+
+// deadlineTimer bounds a single in-flight request with a soft deadline that
+// is independent of (and typically shorter than) the caller's context, so a
+// slow querier doesn't hold a request open for as long as the parent context
+// allows.
+type deadlineTimer struct {
+	timer    *time.Timer
+	stopOnce sync.Once
+}
+
+func newDeadlineTimer(d time.Duration) *deadlineTimer {
+	return &deadlineTimer{
+		timer: time.NewTimer(d),
+	}
+}
+
+// stop releases the timer, so it's safe to call from both the timeout branch
+// and a deferred cleanup.
+func (t *deadlineTimer) stop() {
+	t.stopOnce.Do(func() {
+		if !t.timer.Stop() {
+			select {
+			case <-t.timer.C:
+			default:
+			}
+		}
+	})
+}
+
+// withQueryTimeout runs call, enforcing c's configured query timeout (if any)
+// independently of ctx's own deadline, and retries transient Connect errors
+// with backoff. A generic function rather than a method because Go methods
+// cannot take their own type parameters.
+func withQueryTimeout[T any](c *PyroscopeClient, ctx context.Context, call func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+
+	timeout := c.getQueryTimeout()
+	if timeout <= 0 {
+		return callWithRetry(ctx, call)
+	}
+
+	dt := newDeadlineTimer(timeout)
+	defer dt.stop()
+
+	timeoutCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	type result struct {
+		val T
+		err error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		val, err := callWithRetry(timeoutCtx, call)
+		resCh <- result{val, err}
+	}()
+
+	select {
+	case res := <-resCh:
+		return res.val, res.err
+	case <-dt.timer.C:
+		stop()
+		<-resCh // wait for call to observe the cancellation and return, avoiding a goroutine leak
+		return zero, ErrQueryTimeout
+	}
+}
+
+// callWithRetry retries call with exponential backoff when it fails with a
+// transient Connect error (CodeUnavailable or CodeDeadlineExceeded), so a
+// querier restart doesn't surface as a failed dashboard load.
+func callWithRetry[T any](ctx context.Context, call func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	backoff := initialBackoff
+
+	for attempt := 1; ; attempt++ {
+		val, err := call(ctx)
+		if err == nil || !isRetryableCode(err) || attempt == maxQueryAttempts {
+			return val, err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func isRetryableCode(err error) bool {
+	code := connect.CodeOf(err)
+	return code == connect.CodeUnavailable || code == connect.CodeDeadlineExceeded
+}
+
+// SetQueryTimeout sets the soft per-query deadline applied to every
+// subsequent call. A value <= 0 disables it, leaving cancellation entirely
+// up to the caller's context.
+func (c *PyroscopeClient) SetQueryTimeout(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queryTimeout = d
+}
+
+func (c *PyroscopeClient) getQueryTimeout() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.queryTimeout
+}
@@ -0,0 +1,75 @@
+package pyroscope
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithQueryTimeout_Concurrent(t *testing.T) {
+	c := &PyroscopeClient{}
+	c.SetQueryTimeout(20 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var call func(ctx context.Context) (int, error)
+			if i%2 == 0 {
+				// Completes well within the deadline.
+				call = func(ctx context.Context) (int, error) {
+					return i, nil
+				}
+			} else {
+				// Blocks past the deadline and should surface ErrQueryTimeout.
+				call = func(ctx context.Context) (int, error) {
+					<-ctx.Done()
+					return 0, ctx.Err()
+				}
+			}
+
+			val, err := withQueryTimeout(c, context.Background(), call)
+			if i%2 == 0 {
+				require.NoError(t, err)
+				require.Equal(t, i, val)
+			} else {
+				require.ErrorIs(t, err, ErrQueryTimeout)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCallWithRetry_RetriesRetryableCodes(t *testing.T) {
+	attempts := 0
+	val, err := callWithRetry(context.Background(), func(ctx context.Context) (int, error) {
+		attempts++
+		if attempts < maxQueryAttempts {
+			return 0, connect.NewError(connect.CodeUnavailable, errors.New("querier restarting"))
+		}
+		return 42, nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, 42, val)
+	require.Equal(t, maxQueryAttempts, attempts)
+}
+
+func TestCallWithRetry_DoesNotRetryOtherCodes(t *testing.T) {
+	attempts := 0
+	_, err := callWithRetry(context.Background(), func(ctx context.Context) (int, error) {
+		attempts++
+		return 0, connect.NewError(connect.CodeInvalidArgument, errors.New("bad selector"))
+	})
+
+	require.Error(t, err)
+	require.Equal(t, 1, attempts)
+}
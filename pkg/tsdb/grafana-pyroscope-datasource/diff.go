@@ -0,0 +1,205 @@
+package pyroscope
+
+// valuesPerNode is the number of int64s encoding one node in a Level.Values
+// slice: offset, total, self, nameIndex.
+const valuesPerNode = 4
+
+// diffValuesPerNode is the number of int64s encoding one node in a
+// DiffLevel.Values slice: offset, leftTotal, leftSelf, rightTotal,
+// rightSelf, nameIndex.
+const diffValuesPerNode = 6
+
+type flamebearerNode struct {
+	offset int64
+	total  int64
+	self   int64
+	name   string
+}
+
+// decodeLevel expands a Level's flat Values into one flamebearerNode per
+// node, resolving the name index against names.
+func decodeLevel(level *Level, names []string) []flamebearerNode {
+	if level == nil {
+		return nil
+	}
+
+	nodes := make([]flamebearerNode, 0, len(level.Values)/valuesPerNode)
+	for i := 0; i+valuesPerNode <= len(level.Values); i += valuesPerNode {
+		nameIdx := level.Values[i+3]
+		var name string
+		if nameIdx >= 0 && int(nameIdx) < len(names) {
+			name = names[nameIdx]
+		}
+		nodes = append(nodes, flamebearerNode{
+			offset: level.Values[i],
+			total:  level.Values[i+1],
+			self:   level.Values[i+2],
+			name:   name,
+		})
+	}
+	return nodes
+}
+
+// mergeFlamebearers aligns left and right by name at every level, zero-filling
+// whichever side is missing a given node. Either side may be nil, e.g. when a
+// query returned no data for that side's time range.
+func mergeFlamebearers(left, right *Flamebearer) *DiffFlamebearer {
+	mergedNames, nameIndex := mergeNames(left, right)
+
+	levelCount := 0
+	if left != nil && len(left.Levels) > levelCount {
+		levelCount = len(left.Levels)
+	}
+	if right != nil && len(right.Levels) > levelCount {
+		levelCount = len(right.Levels)
+	}
+
+	var leftTotal, rightTotal int64
+	if left != nil {
+		leftTotal = left.Total
+	}
+	if right != nil {
+		rightTotal = right.Total
+	}
+
+	levels := make([]*DiffLevel, levelCount)
+	for i := 0; i < levelCount; i++ {
+		var leftLevel, rightLevel *Level
+		if left != nil && i < len(left.Levels) {
+			leftLevel = left.Levels[i]
+		}
+		if right != nil && i < len(right.Levels) {
+			rightLevel = right.Levels[i]
+		}
+
+		var leftNames, rightNames []string
+		if left != nil {
+			leftNames = left.Names
+		}
+		if right != nil {
+			rightNames = right.Names
+		}
+
+		levels[i] = mergeLevel(decodeLevel(leftLevel, leftNames), decodeLevel(rightLevel, rightNames), nameIndex, leftTotal, rightTotal)
+	}
+
+	return &DiffFlamebearer{
+		Names:      mergedNames,
+		Levels:     levels,
+		LeftTotal:  leftTotal,
+		RightTotal: rightTotal,
+	}
+}
+
+// mergeNames returns the union of left's and right's names, preserving
+// left's order and appending any right-only names, along with a lookup from
+// name to its index in the merged slice.
+func mergeNames(left, right *Flamebearer) ([]string, map[string]int) {
+	nameIndex := make(map[string]int)
+	var merged []string
+
+	add := func(names []string) {
+		for _, name := range names {
+			if _, ok := nameIndex[name]; !ok {
+				nameIndex[name] = len(merged)
+				merged = append(merged, name)
+			}
+		}
+	}
+
+	if left != nil {
+		add(left.Names)
+	}
+	if right != nil {
+		add(right.Names)
+	}
+
+	return merged, nameIndex
+}
+
+// levelEnd returns the absolute position just past the last node in nodes,
+// walking the sequence exactly as decodeLevel's offsets intend (each node's
+// offset is the gap from the previous sibling's end).
+func levelEnd(nodes []flamebearerNode) int64 {
+	var cursor int64
+	for _, n := range nodes {
+		cursor += n.offset + n.total
+	}
+	return cursor
+}
+
+// mergeLevel aligns leftNodes and rightNodes by name. Left's nodes are
+// emitted verbatim, in their original order with their original offsets —
+// those offsets already encode correct adjacency, including two siblings
+// that share a name (recursion, or a shared utility called from two
+// parents), so left is never reordered or collapsed. Each left node pairs
+// with the next not-yet-consumed right node of the same name (earliest
+// first), so repeated names pair up in the order they occur rather than
+// summing across occurrences, which would swallow whatever distinct sibling
+// originally sat between them. Whatever right nodes are left unpaired
+// (right-only, or extra occurrences of a repeated name) are appended
+// afterwards in their original relative order. leftTotal and rightTotal are
+// each side's profile total, needed to rescale those right-only positions
+// onto left's scale: the two sides are measured against different totals,
+// so a right-side position can't be reused as-is without distorting the
+// merged layout.
+func mergeLevel(leftNodes, rightNodes []flamebearerNode, nameIndex map[string]int, leftTotal, rightTotal int64) *DiffLevel {
+	// unpaired holds, per name, the indices into rightNodes not yet claimed
+	// by a left node, in original order.
+	unpaired := make(map[string][]int, len(rightNodes))
+	for i, n := range rightNodes {
+		unpaired[n.name] = append(unpaired[n.name], i)
+	}
+
+	values := make([]int64, 0, (len(leftNodes)+len(rightNodes))*diffValuesPerNode)
+
+	for _, l := range leftNodes {
+		var r flamebearerNode
+		if q := unpaired[l.name]; len(q) > 0 {
+			r = rightNodes[q[0]]
+			unpaired[l.name] = q[1:]
+		}
+		values = append(values, l.offset, l.total, l.self, r.total, r.self, int64(nameIndex[l.name]))
+	}
+
+	rightScale := func(pos int64) int64 {
+		if rightTotal == 0 {
+			return 0
+		}
+		if leftTotal == 0 {
+			return pos
+		}
+		return pos * leftTotal / rightTotal
+	}
+
+	// prevEnd tracks the end of the previously emitted node, so a right-only
+	// node's offset is the gap from that end rather than a raw right-side
+	// position reused verbatim.
+	prevEnd := levelEnd(leftNodes)
+	var cursor int64
+	for i, r := range rightNodes {
+		cursor += r.offset
+		pos := cursor
+		cursor += r.total
+
+		q := unpaired[r.name]
+		if len(q) == 0 || q[0] != i {
+			continue // already paired with a left node above
+		}
+		unpaired[r.name] = q[1:]
+
+		scaledPos := rightScale(pos)
+		offset := scaledPos - prevEnd
+		if offset < 0 {
+			// The merged ordering (all left nodes, then leftover right
+			// nodes) can't always reflect true spatial overlap once
+			// positions are rescaled; clamp rather than emit a negative
+			// gap, which would corrupt rendering.
+			offset = 0
+		}
+		values = append(values, offset, 0, 0, r.total, r.self, int64(nameIndex[r.name]))
+		prevEnd = scaledPos + rightScale(r.total)
+	}
+
+	return &DiffLevel{Values: values}
+}
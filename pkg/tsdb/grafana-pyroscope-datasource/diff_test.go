@@ -0,0 +1,118 @@
+package pyroscope
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeFlamebearers_ZeroFillsMissingSide(t *testing.T) {
+	left := &Flamebearer{
+		Names: []string{"total", "a", "b"},
+		Levels: []*Level{
+			{Values: []int64{0, 100, 0, 0}},
+			{Values: []int64{0, 60, 10, 1, 60, 40, 30, 2}},
+		},
+		Total: 100,
+	}
+
+	right := &Flamebearer{
+		Names: []string{"total", "a", "c"},
+		Levels: []*Level{
+			{Values: []int64{0, 80, 0, 0}},
+			{Values: []int64{0, 50, 20, 1, 50, 30, 10, 2}},
+		},
+		Total: 80,
+	}
+
+	diff := mergeFlamebearers(left, right)
+
+	require.Equal(t, []string{"total", "a", "b", "c"}, diff.Names)
+	require.Equal(t, int64(100), diff.LeftTotal)
+	require.Equal(t, int64(80), diff.RightTotal)
+	require.Len(t, diff.Levels, 2)
+
+	// Level 1 has "a" on both sides, "b" left only, and "c" right only; the
+	// side missing a node must be zero-filled rather than dropping the node.
+	level := diff.Levels[1]
+	require.Len(t, level.Values, 3*diffValuesPerNode)
+
+	aGroup := level.Values[0*diffValuesPerNode : 1*diffValuesPerNode]
+	require.Equal(t, []int64{0, 60, 10, 50, 20, 1}, aGroup)
+
+	bGroup := level.Values[1*diffValuesPerNode : 2*diffValuesPerNode]
+	require.Equal(t, []int64{60, 40, 30, 0, 0, 2}, bGroup)
+
+	// c is right-only: its offset is recomputed relative to the merged
+	// sequence (and rescaled onto left's total) rather than reusing right's
+	// raw source-side offset of 50, which was measured against a different
+	// total and would misalign the merged layout.
+	cGroup := level.Values[2*diffValuesPerNode : 3*diffValuesPerNode]
+	require.Equal(t, []int64{0, 0, 0, 30, 10, 3}, cGroup)
+}
+
+func TestMergeLevel_KeepsSameNamedSiblingsAsSeparateRows(t *testing.T) {
+	nameIndex := map[string]int{"total": 0, "dup": 1}
+	left := []flamebearerNode{
+		{offset: 0, total: 10, self: 10, name: "dup"},
+		{offset: 5, total: 20, self: 20, name: "dup"},
+	}
+
+	level := mergeLevel(left, nil, nameIndex, 30, 0)
+
+	// Both same-named siblings must contribute their samples as their own
+	// row, at their own original offset, rather than being collapsed into
+	// one combined (and mispositioned) node.
+	require.Equal(t, []int64{
+		0, 10, 10, 0, 0, 1,
+		5, 20, 20, 0, 0, 1,
+	}, level.Values)
+}
+
+func TestMergeLevel_DoesNotSwallowDistinctSiblingBetweenRepeatedName(t *testing.T) {
+	nameIndex := map[string]int{"dup": 0, "x": 1}
+	// Two "dup" occurrences (e.g. recursion) with an unrelated sibling "x"
+	// sitting between them — merging must not collapse the two "dup"s into
+	// one span, which would push "x" out from between them.
+	left := []flamebearerNode{
+		{offset: 0, total: 10, self: 10, name: "dup"},
+		{offset: 5, total: 3, self: 3, name: "x"},
+		{offset: 2, total: 20, self: 20, name: "dup"},
+	}
+
+	level := mergeLevel(left, nil, nameIndex, 40, 0)
+
+	require.Equal(t, []int64{
+		0, 10, 10, 0, 0, 0,
+		5, 3, 3, 0, 0, 1,
+		2, 20, 20, 0, 0, 0,
+	}, level.Values)
+}
+
+func TestMergeLevel_RecomputesOffsetsAcrossDifferentScales(t *testing.T) {
+	nameIndex := map[string]int{"a": 0, "b": 1}
+	left := []flamebearerNode{{offset: 0, total: 20, self: 20, name: "a"}}
+	right := []flamebearerNode{{offset: 100, total: 30, self: 30, name: "b"}}
+
+	// leftTotal (200) is twice rightTotal (100); a right-only node's offset
+	// must be rescaled onto left's total rather than copied verbatim.
+	level := mergeLevel(left, right, nameIndex, 200, 100)
+
+	require.Equal(t, []int64{0, 20, 20, 0, 0, 0}, level.Values[0:diffValuesPerNode])
+	require.Equal(t, []int64{180, 0, 0, 30, 30, 1}, level.Values[diffValuesPerNode:2*diffValuesPerNode])
+}
+
+func TestMergeFlamebearers_NilSide(t *testing.T) {
+	left := &Flamebearer{
+		Names:  []string{"total"},
+		Levels: []*Level{{Values: []int64{0, 10, 10, 0}}},
+		Total:  10,
+	}
+
+	diff := mergeFlamebearers(left, nil)
+
+	require.Equal(t, []string{"total"}, diff.Names)
+	require.Equal(t, int64(10), diff.LeftTotal)
+	require.Equal(t, int64(0), diff.RightTotal)
+	require.Equal(t, []int64{0, 10, 10, 0, 0, 0}, diff.Levels[0].Values)
+}
@@ -0,0 +1,98 @@
+package pyroscope
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	querierv1 "github.com/grafana/phlare/api/gen/proto/go/querier/v1"
+	"github.com/grafana/phlare/api/gen/proto/go/querier/v1/querierv1connect"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLabelsClient embeds the (nil) real interface so tests only need to
+// implement the two RPCs they exercise, and records the last request sent so
+// tests can assert on how params were translated.
+type fakeLabelsClient struct {
+	querierv1connect.QuerierServiceClient
+
+	lastNamesReq  *querierv1.LabelNamesRequest
+	namesResp     []string
+	lastValuesReq *querierv1.LabelValuesRequest
+	valuesResp    []string
+}
+
+func (f *fakeLabelsClient) LabelNames(ctx context.Context, req *connect.Request[querierv1.LabelNamesRequest]) (*connect.Response[querierv1.LabelNamesResponse], error) {
+	f.lastNamesReq = req.Msg
+	return connect.NewResponse(&querierv1.LabelNamesResponse{Names: f.namesResp}), nil
+}
+
+func (f *fakeLabelsClient) LabelValues(ctx context.Context, req *connect.Request[querierv1.LabelValuesRequest]) (*connect.Response[querierv1.LabelValuesResponse], error) {
+	f.lastValuesReq = req.Msg
+	return connect.NewResponse(&querierv1.LabelValuesResponse{Names: f.valuesResp}), nil
+}
+
+func TestLabelNames_PassesMatcherRangeAndLimit(t *testing.T) {
+	fake := &fakeLabelsClient{namesResp: []string{"service", "pod"}}
+	c := &PyroscopeClient{connectClient: fake}
+
+	resp, err := c.LabelNames(context.Background(), `{service="foo"}`, 10, 20, 2)
+
+	require.NoError(t, err)
+	require.Equal(t, []string{`{service="foo"}`}, fake.lastNamesReq.Matchers)
+	require.Equal(t, int64(10), fake.lastNamesReq.Start)
+	require.Equal(t, int64(20), fake.lastNamesReq.End)
+	require.Equal(t, int64(2), *fake.lastNamesReq.Limit)
+	require.Equal(t, []string{"service", "pod"}, resp.Names)
+	require.True(t, resp.Truncated)
+}
+
+func TestLabelNames_NoLimitMeansNoLimitFieldAndNotTruncated(t *testing.T) {
+	fake := &fakeLabelsClient{namesResp: []string{"service"}}
+	c := &PyroscopeClient{connectClient: fake}
+
+	resp, err := c.LabelNames(context.Background(), "", 0, 0, 0)
+
+	require.NoError(t, err)
+	require.Nil(t, fake.lastNamesReq.Matchers)
+	require.Nil(t, fake.lastNamesReq.Limit)
+	require.False(t, resp.Truncated)
+}
+
+func TestLabelNames_FiltersPrivateLabels(t *testing.T) {
+	fake := &fakeLabelsClient{namesResp: []string{"service", "__name__", "pod"}}
+	c := &PyroscopeClient{connectClient: fake}
+
+	resp, err := c.LabelNames(context.Background(), "", 0, 0, 0)
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"service", "pod"}, resp.Names)
+}
+
+func TestLabelValues_PassesMatcherQueryRangeAndLimit(t *testing.T) {
+	fake := &fakeLabelsClient{valuesResp: []string{"foo", "bar"}}
+	c := &PyroscopeClient{connectClient: fake}
+
+	resp, err := c.LabelValues(context.Background(), "service", `{env="prod"}`, "fo", 10, 20, 2)
+
+	require.NoError(t, err)
+	require.Equal(t, "service", fake.lastValuesReq.Name)
+	require.Equal(t, []string{`{env="prod"}`}, fake.lastValuesReq.Matchers)
+	require.Equal(t, "fo", fake.lastValuesReq.Query)
+	require.Equal(t, int64(10), fake.lastValuesReq.Start)
+	require.Equal(t, int64(20), fake.lastValuesReq.End)
+	require.Equal(t, int64(2), *fake.lastValuesReq.Limit)
+	require.Equal(t, []string{"foo", "bar"}, resp.Values)
+	require.True(t, resp.Truncated)
+}
+
+func TestLabelValues_NoLimitMeansNotTruncated(t *testing.T) {
+	fake := &fakeLabelsClient{valuesResp: []string{"foo"}}
+	c := &PyroscopeClient{connectClient: fake}
+
+	resp, err := c.LabelValues(context.Background(), "service", "", "", 0, 0, 0)
+
+	require.NoError(t, err)
+	require.Nil(t, fake.lastValuesReq.Limit)
+	require.False(t, resp.Truncated)
+}
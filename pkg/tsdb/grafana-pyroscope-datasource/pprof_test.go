@@ -0,0 +1,56 @@
+package pyroscope
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	querierv1 "github.com/grafana/phlare/api/gen/proto/go/querier/v1"
+	"github.com/grafana/phlare/api/gen/proto/go/querier/v1/querierv1connect"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQuerierClient embeds the (nil) real interface so tests only need to
+// implement the one RPC they exercise; any other method would panic if
+// called, which these tests never do.
+type fakeQuerierClient struct {
+	querierv1connect.QuerierServiceClient
+
+	resp *connect.Response[querierv1.SelectMergeProfileResponse]
+	err  error
+}
+
+func (f *fakeQuerierClient) SelectMergeProfile(ctx context.Context, req *connect.Request[querierv1.SelectMergeProfileRequest]) (*connect.Response[querierv1.SelectMergeProfileResponse], error) {
+	return f.resp, f.err
+}
+
+func TestBuildPprofResponse_GzipsAndDerivesFilename(t *testing.T) {
+	raw := []byte("not actually a pprof profile, just some bytes")
+
+	resp, err := buildPprofResponse("process_cpu:cpu:nanoseconds:cpu:nanoseconds", raw)
+
+	require.NoError(t, err)
+	require.Equal(t, "process_cpu-cpu-nanoseconds-cpu-nanoseconds.pprof.gz", resp.Filename)
+
+	gzr, err := gzip.NewReader(bytes.NewReader(resp.Data))
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(gzr)
+	require.NoError(t, err)
+	require.Equal(t, raw, decompressed)
+}
+
+func TestGetProfilePprof_NilProfileIsNotAnError(t *testing.T) {
+	c := &PyroscopeClient{
+		connectClient: &fakeQuerierClient{
+			resp: connect.NewResponse(&querierv1.SelectMergeProfileResponse{Profile: nil}),
+		},
+	}
+
+	resp, err := c.GetProfilePprof(context.Background(), "process_cpu:cpu:nanoseconds:cpu:nanoseconds", "{}", 0, 1)
+
+	require.NoError(t, err)
+	require.Nil(t, resp)
+}
@@ -1,16 +1,45 @@
 package pyroscope
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bufbuild/connect-go"
 	querierv1 "github.com/grafana/phlare/api/gen/proto/go/querier/v1"
 	"github.com/grafana/phlare/api/gen/proto/go/querier/v1/querierv1connect"
+	"google.golang.org/protobuf/proto"
 )
 
+// PprofMIMEType is the MIME type returned for pprof exports, matching the
+// content type the pprof/speedscope/flamegraph ecosystem expects.
+const PprofMIMEType = "application/vnd.google.protobuf"
+
+// defaultTenantHeader is the header used to scope requests to a tenant when
+// talking to a multi-tenant Phlare/Pyroscope cluster, matching the default
+// used by Mimir/Loki/Thanos.
+const defaultTenantHeader = "X-Scope-OrgID"
+
+type tenantContextKey struct{}
+
+// WithTenant returns a copy of ctx carrying tenantID, overriding whatever
+// tenant (if any) was configured on the PyroscopeClient for calls made with
+// this context. Multiple tenants can be passed as a comma-separated string,
+// which is forwarded to Phlare unchanged.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+func tenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenantID, ok && tenantID != ""
+}
+
 type ProfileType struct {
 	ID    string `json:"id"`
 	Label string `json:"label"`
@@ -48,24 +77,116 @@ type ProfileResponse struct {
 	Units       string
 }
 
+// PprofResponse holds a gzipped pprof-format profile, ready to be served as
+// a downloadable file compatible with `go tool pprof`, speedscope, etc.
+type PprofResponse struct {
+	Data     []byte
+	Filename string
+}
+
+// DiffFlamebearer is a Flamebearer carrying both a left and a right value for
+// every node, so the frontend can render a comparison flamegraph. Names is
+// the union of the names appearing on either side; nodes that only exist on
+// one side have the other side's values zero-filled.
+type DiffFlamebearer struct {
+	Names      []string
+	Levels     []*DiffLevel
+	LeftTotal  int64
+	RightTotal int64
+}
+
+// DiffLevel mirrors Level, but each node is encoded as a group of 6 values:
+// offset, leftTotal, leftSelf, rightTotal, rightSelf, nameIndex.
+type DiffLevel struct {
+	Values []int64
+}
+
+// DiffProfileResponse wraps a DiffFlamebearer the same way ProfileResponse
+// wraps a Flamebearer.
+type DiffProfileResponse struct {
+	Flamebearer *DiffFlamebearer
+	Units       string
+}
+
 type SeriesResponse struct {
 	Series []*Series
 	Units  string
 	Label  string
 }
 
+// LabelNamesResponse is the result of a (possibly limited) LabelNames call.
+type LabelNamesResponse struct {
+	Names []string
+	// Truncated is true when limit cut off the result, so the caller (e.g.
+	// the variable/autocomplete UI) knows there may be more values.
+	Truncated bool
+}
+
+// LabelValuesResponse is the result of a (possibly limited) LabelValues call.
+type LabelValuesResponse struct {
+	Values    []string
+	Truncated bool
+}
+
 type PyroscopeClient struct {
 	connectClient querierv1connect.QuerierServiceClient
+
+	// tenantID is sent as tenantHeader on every outgoing request unless
+	// overridden per-call via WithTenant. It may be a comma-separated list
+	// of tenants, which is forwarded to Phlare unchanged.
+	tenantID     string
+	tenantHeader string
+
+	// mu guards queryTimeout, which can be changed concurrently with
+	// in-flight requests via SetQueryTimeout.
+	mu           sync.RWMutex
+	queryTimeout time.Duration
 }
 
-func NewPyroscopeClient(httpClient *http.Client, url string) *PyroscopeClient {
-	return &PyroscopeClient{
+// ClientOption configures optional behavior of a PyroscopeClient.
+type ClientOption func(*PyroscopeClient)
+
+// WithTenantHeader overrides the header used to carry the tenant ID, which
+// defaults to X-Scope-OrgID.
+func WithTenantHeader(header string) ClientOption {
+	return func(c *PyroscopeClient) {
+		c.tenantHeader = header
+	}
+}
+
+func NewPyroscopeClient(httpClient *http.Client, url string, tenantID string, opts ...ClientOption) *PyroscopeClient {
+	c := &PyroscopeClient{
 		connectClient: querierv1connect.NewQuerierServiceClient(httpClient, url),
+		tenantID:      tenantID,
+		tenantHeader:  defaultTenantHeader,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// setTenantHeader stamps header with the tenant for ctx, preferring a tenant
+// set via WithTenant over the client's configured default.
+func (c *PyroscopeClient) setTenantHeader(ctx context.Context, header http.Header) {
+	tenantID := c.tenantID
+	if ctxTenantID, ok := tenantFromContext(ctx); ok {
+		tenantID = ctxTenantID
+	}
+	if tenantID != "" {
+		header.Set(c.tenantHeader, tenantID)
 	}
 }
 
 func (c *PyroscopeClient) ProfileTypes(ctx context.Context) ([]*ProfileType, error) {
-	res, err := c.connectClient.ProfileTypes(ctx, connect.NewRequest(&querierv1.ProfileTypesRequest{}))
+	req := connect.NewRequest(&querierv1.ProfileTypesRequest{})
+	c.setTenantHeader(ctx, req.Header())
+
+	res, err := withQueryTimeout(c, ctx, func(ctx context.Context) (*connect.Response[querierv1.ProfileTypesResponse], error) {
+		return c.connectClient.ProfileTypes(ctx, req)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -93,8 +214,11 @@ func (c *PyroscopeClient) GetSeries(ctx context.Context, profileTypeID string, l
 		Step:          step,
 		GroupBy:       groupBy,
 	})
+	c.setTenantHeader(ctx, req.Header())
 
-	resp, err := c.connectClient.SelectSeries(ctx, req)
+	resp, err := withQueryTimeout(c, ctx, func(ctx context.Context) (*connect.Response[querierv1.SelectSeriesResponse], error) {
+		return c.connectClient.SelectSeries(ctx, req)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -143,8 +267,11 @@ func (c *PyroscopeClient) GetProfile(ctx context.Context, profileTypeID, labelSe
 			MaxNodes:      maxNodes,
 		},
 	}
+	c.setTenantHeader(ctx, req.Header())
 
-	resp, err := c.connectClient.SelectMergeStacktraces(ctx, req)
+	resp, err := withQueryTimeout(c, ctx, func(ctx context.Context) (*connect.Response[querierv1.SelectMergeStacktracesResponse], error) {
+		return c.connectClient.SelectMergeStacktraces(ctx, req)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -172,6 +299,93 @@ func (c *PyroscopeClient) GetProfile(ctx context.Context, profileTypeID, labelSe
 	}, nil
 }
 
+// GetProfileDiff merges profiles on each side of the comparison independently
+// (left: leftSelector over [leftStart, leftEnd], right: rightSelector over
+// [rightStart, rightEnd]) and aligns them into a single DiffFlamebearer so
+// the frontend can render a diff flamegraph.
+func (c *PyroscopeClient) GetProfileDiff(ctx context.Context, profileTypeID, leftSelector string, leftStart, leftEnd int64, rightSelector string, rightStart, rightEnd int64, maxNodes *int64) (*DiffProfileResponse, error) {
+	left, err := c.GetProfile(ctx, profileTypeID, leftSelector, leftStart, leftEnd, maxNodes)
+	if err != nil {
+		return nil, fmt.Errorf("error getting left side of diff: %w", err)
+	}
+
+	right, err := c.GetProfile(ctx, profileTypeID, rightSelector, rightStart, rightEnd, maxNodes)
+	if err != nil {
+		return nil, fmt.Errorf("error getting right side of diff: %w", err)
+	}
+
+	var leftFlamebearer, rightFlamebearer *Flamebearer
+	if left != nil {
+		leftFlamebearer = left.Flamebearer
+	}
+	if right != nil {
+		rightFlamebearer = right.Flamebearer
+	}
+
+	if leftFlamebearer == nil && rightFlamebearer == nil {
+		// Not an error, can happen when querying data out of range on both sides.
+		return nil, nil
+	}
+
+	return &DiffProfileResponse{
+		Flamebearer: mergeFlamebearers(leftFlamebearer, rightFlamebearer),
+		Units:       getUnits(profileTypeID),
+	}, nil
+}
+
+// GetProfilePprof merges profiles matching labelSelector over [start, end]
+// the same way GetProfile does, but returns the result as a gzipped pprof
+// protobuf (Phlare's SelectMergeProfile) instead of a Flamebearer, so it can
+// be downloaded and opened in `go tool pprof` or speedscope.
+func (c *PyroscopeClient) GetProfilePprof(ctx context.Context, profileTypeID, labelSelector string, start, end int64) (*PprofResponse, error) {
+	req := connect.NewRequest(&querierv1.SelectMergeProfileRequest{
+		ProfileTypeID: profileTypeID,
+		LabelSelector: labelSelector,
+		Start:         start,
+		End:           end,
+	})
+	c.setTenantHeader(ctx, req.Header())
+
+	resp, err := withQueryTimeout(c, ctx, func(ctx context.Context) (*connect.Response[querierv1.SelectMergeProfileResponse], error) {
+		return c.connectClient.SelectMergeProfile(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Msg.Profile == nil {
+		// Not an error, can happen when querying data out of range.
+		return nil, nil
+	}
+
+	raw, err := proto.Marshal(resp.Msg.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling pprof profile: %v", err)
+	}
+
+	return buildPprofResponse(profileTypeID, raw)
+}
+
+// buildPprofResponse gzips raw (an already-marshaled pprof protobuf) and
+// derives a download filename from profileTypeID by replacing each ':' with
+// '-', e.g. "process_cpu:cpu:nanoseconds:cpu:nanoseconds" becomes
+// "process_cpu-cpu-nanoseconds-cpu-nanoseconds.pprof.gz".
+func buildPprofResponse(profileTypeID string, raw []byte) (*PprofResponse, error) {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	if _, err := gzw.Write(raw); err != nil {
+		return nil, fmt.Errorf("error gzipping pprof profile: %v", err)
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, fmt.Errorf("error gzipping pprof profile: %v", err)
+	}
+
+	return &PprofResponse{
+		Data:     buf.Bytes(),
+		Filename: strings.ReplaceAll(profileTypeID, ":", "-") + ".pprof.gz",
+	}, nil
+}
+
 func getUnits(profileTypeID string) string {
 	parts := strings.Split(profileTypeID, ":")
 	unit := parts[2]
@@ -184,8 +398,24 @@ func getUnits(profileTypeID string) string {
 	return unit
 }
 
-func (c *PyroscopeClient) LabelNames(ctx context.Context) ([]string, error) {
-	resp, err := c.connectClient.LabelNames(ctx, connect.NewRequest(&querierv1.LabelNamesRequest{}))
+// LabelNames lists label names, optionally scoped to series matching matcher
+// (a Prometheus-style selector, e.g. `{service="foo"}`) over [start, end].
+// limit caps the number of names returned; pass 0 for no limit.
+func (c *PyroscopeClient) LabelNames(ctx context.Context, matcher string, start, end, limit int64) (*LabelNamesResponse, error) {
+	labelReq := &querierv1.LabelNamesRequest{Start: start, End: end}
+	if matcher != "" {
+		labelReq.Matchers = []string{matcher}
+	}
+	if limit > 0 {
+		labelReq.Limit = &limit
+	}
+
+	req := connect.NewRequest(labelReq)
+	c.setTenantHeader(ctx, req.Header())
+
+	resp, err := withQueryTimeout(c, ctx, func(ctx context.Context) (*connect.Response[querierv1.LabelNamesResponse], error) {
+		return c.connectClient.LabelNames(ctx, req)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error seding LabelNames request %v", err)
 	}
@@ -197,15 +427,45 @@ func (c *PyroscopeClient) LabelNames(ctx context.Context) ([]string, error) {
 		}
 	}
 
-	return filtered, nil
+	return &LabelNamesResponse{
+		Names:     filtered,
+		Truncated: limit > 0 && int64(len(resp.Msg.Names)) >= limit,
+	}, nil
 }
 
-func (c *PyroscopeClient) LabelValues(ctx context.Context, label string) ([]string, error) {
-	resp, err := c.connectClient.LabelValues(ctx, connect.NewRequest(&querierv1.LabelValuesRequest{Name: label}))
+// LabelValues lists the values of label, optionally scoped to series matching
+// matcher (a Prometheus-style selector) over [start, end] and filtered
+// server-side by query, a substring/regex against the label value. limit
+// caps the number of values returned; pass 0 for no limit. This mirrors how
+// the Prometheus/Loki datasources scope label lookups so the variable and
+// autocomplete UI doesn't have to fetch every value for high-cardinality
+// labels like `pod` or `span_name`.
+func (c *PyroscopeClient) LabelValues(ctx context.Context, label, matcher, query string, start, end, limit int64) (*LabelValuesResponse, error) {
+	labelReq := &querierv1.LabelValuesRequest{Name: label, Start: start, End: end}
+	if matcher != "" {
+		labelReq.Matchers = []string{matcher}
+	}
+	if query != "" {
+		labelReq.Query = query
+	}
+	if limit > 0 {
+		labelReq.Limit = &limit
+	}
+
+	req := connect.NewRequest(labelReq)
+	c.setTenantHeader(ctx, req.Header())
+
+	resp, err := withQueryTimeout(c, ctx, func(ctx context.Context) (*connect.Response[querierv1.LabelValuesResponse], error) {
+		return c.connectClient.LabelValues(ctx, req)
+	})
 	if err != nil {
 		return nil, err
 	}
-	return resp.Msg.Names, nil
+
+	return &LabelValuesResponse{
+		Values:    resp.Msg.Names,
+		Truncated: limit > 0 && int64(len(resp.Msg.Names)) >= limit,
+	}, nil
 }
 
 func isPrivateLabel(label string) bool {
@@ -0,0 +1,112 @@
+package pyroscope
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+)
+
+// Datasource exposes PyroscopeClient over the resource routes the frontend
+// calls directly (outside the query model), such as downloading a profile
+// in pprof format.
+type Datasource struct {
+	Client *PyroscopeClient
+}
+
+func NewDatasource(client *PyroscopeClient) *Datasource {
+	return &Datasource{Client: client}
+}
+
+// CallResource implements backend.CallResourceHandler.
+func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pprof", d.handlePprof)
+	mux.HandleFunc("/diff", d.handleDiff)
+	return httpadapter.New(mux).CallResource(ctx, req, sender)
+}
+
+// handlePprof serves the gzipped pprof export backing the panel's "Download
+// pprof" action.
+func (d *Datasource) handlePprof(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+
+	start, err := strconv.ParseInt(q.Get("start"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid start", http.StatusBadRequest)
+		return
+	}
+	end, err := strconv.ParseInt(q.Get("end"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid end", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := d.Client.GetProfilePprof(req.Context(), q.Get("profileTypeId"), q.Get("labelSelector"), start, end)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if profile == nil {
+		http.Error(w, "no data for the given time range", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", PprofMIMEType)
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+profile.Filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(profile.Data)
+}
+
+// handleDiff serves the diff flamegraph backing the panel's comparison view.
+func (d *Datasource) handleDiff(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+
+	leftStart, err := strconv.ParseInt(q.Get("leftStart"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid leftStart", http.StatusBadRequest)
+		return
+	}
+	leftEnd, err := strconv.ParseInt(q.Get("leftEnd"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid leftEnd", http.StatusBadRequest)
+		return
+	}
+	rightStart, err := strconv.ParseInt(q.Get("rightStart"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid rightStart", http.StatusBadRequest)
+		return
+	}
+	rightEnd, err := strconv.ParseInt(q.Get("rightEnd"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid rightEnd", http.StatusBadRequest)
+		return
+	}
+
+	var maxNodes *int64
+	if v := q.Get("maxNodes"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid maxNodes", http.StatusBadRequest)
+			return
+		}
+		maxNodes = &n
+	}
+
+	diff, err := d.Client.GetProfileDiff(req.Context(), q.Get("profileTypeId"), q.Get("leftSelector"), leftStart, leftEnd, q.Get("rightSelector"), rightStart, rightEnd, maxNodes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if diff == nil {
+		http.Error(w, "no data for the given time ranges", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(diff)
+}
@@ -0,0 +1,48 @@
+package pyroscope
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	querierv1 "github.com/grafana/phlare/api/gen/proto/go/querier/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePprof_MissingParamsReturnsBadRequest(t *testing.T) {
+	d := NewDatasource(&PyroscopeClient{})
+
+	req := httptest.NewRequest(http.MethodGet, "/pprof", nil)
+	rr := httptest.NewRecorder()
+
+	d.handlePprof(rr, req)
+
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestHandlePprof_NoDataReturnsNotFound(t *testing.T) {
+	d := NewDatasource(&PyroscopeClient{
+		connectClient: &fakeQuerierClient{
+			resp: connect.NewResponse(&querierv1.SelectMergeProfileResponse{Profile: nil}),
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/pprof?profileTypeId=process_cpu:cpu:nanoseconds:cpu:nanoseconds&labelSelector={}&start=0&end=1", nil)
+	rr := httptest.NewRecorder()
+
+	d.handlePprof(rr, req)
+
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestHandleDiff_MissingParamsReturnsBadRequest(t *testing.T) {
+	d := NewDatasource(&PyroscopeClient{})
+
+	req := httptest.NewRequest(http.MethodGet, "/diff", nil)
+	rr := httptest.NewRecorder()
+
+	d.handleDiff(rr, req)
+
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
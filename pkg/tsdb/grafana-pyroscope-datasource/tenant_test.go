@@ -0,0 +1,57 @@
+package pyroscope
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetTenantHeader_DefaultHeader(t *testing.T) {
+	c := &PyroscopeClient{tenantID: "tenant-a", tenantHeader: defaultTenantHeader}
+
+	header := http.Header{}
+	c.setTenantHeader(context.Background(), header)
+
+	require.Equal(t, "tenant-a", header.Get(defaultTenantHeader))
+}
+
+func TestSetTenantHeader_CustomHeaderName(t *testing.T) {
+	c := &PyroscopeClient{tenantID: "tenant-a", tenantHeader: "X-Custom-Tenant"}
+
+	header := http.Header{}
+	c.setTenantHeader(context.Background(), header)
+
+	require.Equal(t, "tenant-a", header.Get("X-Custom-Tenant"))
+	require.Empty(t, header.Get(defaultTenantHeader))
+}
+
+func TestSetTenantHeader_ContextOverridesClientDefault(t *testing.T) {
+	c := &PyroscopeClient{tenantID: "tenant-a", tenantHeader: defaultTenantHeader}
+
+	ctx := WithTenant(context.Background(), "tenant-b")
+	header := http.Header{}
+	c.setTenantHeader(ctx, header)
+
+	require.Equal(t, "tenant-b", header.Get(defaultTenantHeader))
+}
+
+func TestSetTenantHeader_EmptyContextTenantDoesNotOverride(t *testing.T) {
+	c := &PyroscopeClient{tenantID: "tenant-a", tenantHeader: defaultTenantHeader}
+
+	ctx := WithTenant(context.Background(), "")
+	header := http.Header{}
+	c.setTenantHeader(ctx, header)
+
+	require.Equal(t, "tenant-a", header.Get(defaultTenantHeader))
+}
+
+func TestSetTenantHeader_NoTenantConfigured(t *testing.T) {
+	c := &PyroscopeClient{tenantHeader: defaultTenantHeader}
+
+	header := http.Header{}
+	c.setTenantHeader(context.Background(), header)
+
+	require.Empty(t, header.Get(defaultTenantHeader))
+}